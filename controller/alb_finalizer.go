@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"github.com/coreos/alb-ingress-controller/log"
+
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// finalizerName is placed on every valid Ingress this controller manages so that, even if the
+// controller is down when the Ingress is deleted, Kubernetes defers the delete until the
+// finalizer is removed. It is only removed once Reconcile() has torn down the ALB, its listeners,
+// rules, target groups and security group. Route53 records are not part of that teardown.
+const finalizerName = "ingress.k8s.aws/alb-ingress-controller"
+
+// ensureFinalizer adds finalizerName to the Ingress if it isn't already present.
+func (ac *ALBController) ensureFinalizer(i *extensions.Ingress) error {
+	if hasFinalizer(i) {
+		return nil
+	}
+
+	updated := copyIngress(i)
+	updated.Finalizers = append(updated.Finalizers, finalizerName)
+	_, err := ac.kubeClient.Extensions().Ingresses(updated.Namespace).Update(updated)
+	return err
+}
+
+// removeFinalizer strips finalizerName from the named Ingress. It is called once an ALBIngress
+// marked for deletion has finished tearing down its AWS resources.
+func (ac *ALBController) removeFinalizer(namespace, name string) error {
+	item, exists, err := ac.storeLister.Ingress.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return err
+	}
+
+	i := item.(*extensions.Ingress)
+	if !hasFinalizer(i) {
+		return nil
+	}
+
+	updated := copyIngress(i)
+	updated.Finalizers = removeString(updated.Finalizers, finalizerName)
+	_, err = ac.kubeClient.Extensions().Ingresses(namespace).Update(updated)
+	if err == nil {
+		log.Infof("Removed %v finalizer from ingress", "controller", finalizerName)
+	}
+	return err
+}
+
+// stripAllFinalizers force-removes finalizerName from every ingress, used for the
+// --finalizer-remove migration escape hatch.
+func (ac *ALBController) stripAllFinalizers() {
+	for _, item := range ac.storeLister.Ingress.List() {
+		i := item.(*extensions.Ingress)
+		if !hasFinalizer(i) {
+			continue
+		}
+		updated := copyIngress(i)
+		updated.Finalizers = removeString(updated.Finalizers, finalizerName)
+		if _, err := ac.kubeClient.Extensions().Ingresses(updated.Namespace).Update(updated); err != nil {
+			log.Errorf("Failed to strip finalizer from %v/%v: %v", "controller", i.Namespace, i.Name, err)
+		}
+	}
+}
+
+// copyIngress returns a shallow copy of i with its own Finalizers slice, safe for callers to
+// mutate. i usually comes straight out of the informer's shared cache via GetByKey/List, and
+// mutating it in place would corrupt every other reader's view of that cache.
+func copyIngress(i *extensions.Ingress) *extensions.Ingress {
+	out := *i
+	out.Finalizers = append([]string(nil), i.Finalizers...)
+	return &out
+}
+
+func hasFinalizer(i *extensions.Ingress) bool {
+	for _, f := range i.Finalizers {
+		if f == finalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(in []string, s string) []string {
+	var out []string
+	for _, v := range in {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
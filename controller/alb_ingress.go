@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/log"
+
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// ingressAnnotationTargetType selects how targets are registered with the ALB target group.
+	// When set to "ip", pod IPs are registered directly instead of the node's NodePort.
+	ingressAnnotationTargetType = "alb.ingress.kubernetes.io/target-type"
+
+	// ingressAnnotationInboundCIDRs restricts the ALB's managed security group to the listed,
+	// comma-separated CIDR blocks instead of leaving it open to 0.0.0.0/0.
+	ingressAnnotationInboundCIDRs = "alb.ingress.kubernetes.io/inbound-cidrs"
+
+	targetTypeInstance = "instance"
+	targetTypeIP       = "ip"
+)
+
+// ALBIngress contains all information needed to assemble an ALB, target group(s), listener(s) and
+// rule(s) for a single Kubernetes ingress resource.
+type ALBIngress struct {
+	id            string
+	namespace     string
+	ingressName   string
+	clusterName   string
+	annotations   map[string]string
+	LoadBalancers LoadBalancersT
+	tainted       bool
+	deleting      bool
+	ac            *ALBController
+}
+
+// NewALBIngressFromIngress creates a new ALBIngress instance from the Kubernetes ingress resource
+// and the existing ALBController state. It is called on every OnUpdate event for every ingress
+// resource known to Kubernetes. prev is this same ingress's ALBIngress from the previous OnUpdate
+// snapshot (or from assembleIngresses on startup), or nil the first time an ingress is seen; its
+// AWS-assigned identifiers are adopted onto the freshly-built tree before it's returned, so
+// Reconcile() doesn't try to create resources that already exist.
+func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController, prev *ALBIngress) (*ALBIngress, error) {
+	ingressID := fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name)
+
+	albIngress := &ALBIngress{
+		id:          ingressID,
+		namespace:   ingress.Namespace,
+		ingressName: ingress.Name,
+		clusterName: *ac.ClusterName,
+		annotations: ingress.Annotations,
+		ac:          ac,
+	}
+
+	targetType := targetTypeInstance
+	if t, ok := ingress.Annotations[ingressAnnotationTargetType]; ok {
+		if t != targetTypeInstance && t != targetTypeIP {
+			return albIngress, fmt.Errorf("%v annotation must be one of '%v' or '%v', got '%v'",
+				ingressAnnotationTargetType, targetTypeInstance, targetTypeIP, t)
+		}
+		targetType = t
+	}
+
+	inboundCIDRs, err := parseInboundCIDRs(ingress.Annotations)
+	if err != nil {
+		return albIngress, fmt.Errorf("%v annotation is invalid: %v", ingressAnnotationInboundCIDRs, err)
+	}
+
+	// Reuse whatever scheme already produced this ingress's resources, if any, so a cluster
+	// migrating to --resource-naming-scheme=v2 adopts its pre-existing v1 resources by name
+	// instead of renaming (and therefore recreating) them.
+	scheme := ac.resourceScheme(prev)
+
+	listenerPorts := []int64{80}
+	if len(ingress.Spec.TLS) > 0 {
+		listenerPorts = append(listenerPorts, 443)
+	}
+	sgName := ac.resourceName("sg", ingress.Namespace, ingress.Name, scheme)
+	securityGroup := NewSecurityGroup(listenerPorts, sgName, scheme, inboundCIDRs)
+
+	listeners := make([]*Listener, 0, len(listenerPorts))
+	for _, port := range listenerPorts {
+		listeners = append(listeners, NewListener(port))
+	}
+
+	var pathCount int
+	for _, rule := range ingress.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			pathCount++
+			serviceKey := fmt.Sprintf("%s/%s", ingress.Namespace, path.Backend.ServiceName)
+
+			targetGroup, err := ac.resolveTargetGroup(serviceKey, targetType, path.Backend.ServicePort.IntVal, scheme)
+			if err != nil {
+				return albIngress, err
+			}
+
+			// Every listener gets its own Rule instance (AWS rules belong to exactly one listener),
+			// sharing the same TargetGroup so the port 80 and port 443 listeners forward to the same
+			// backend. Both listeners are created with the HTTP protocol and no certificate.
+			for _, listener := range listeners {
+				listener.Rules = append(listener.Rules, &Rule{
+					Host:        rule.Host,
+					Path:        path.Path,
+					TargetGroup: targetGroup,
+				})
+			}
+		}
+	}
+
+	// An Ingress with no paths produces no LoadBalancer, matching the previous behavior.
+	if pathCount > 0 {
+		lbName := ac.resourceName("lb", ingress.Namespace, ingress.Name, scheme)
+		lb := NewLoadBalancer(albIngress.clusterName, ingressID, lbName, scheme)
+		lb.SecurityGroup = securityGroup
+		lb.Listeners = listeners
+		albIngress.LoadBalancers = LoadBalancersT{lb}
+	}
+
+	albIngress.adopt(prev)
+	return albIngress, nil
+}
+
+// adopt copies forward AWS-assigned identifiers from prev (this ingress's ALBIngress from the
+// previous OnUpdate snapshot, or from assembleIngresses on startup) onto a. Without this, every
+// OnUpdate would rebuild a's LoadBalancer tree with a nil arn/groupID throughout, and Reconcile()
+// would call CreateLoadBalancer/CreateTargetGroup/CreateSecurityGroup again for resources that
+// were already created on a previous cycle.
+func (a *ALBIngress) adopt(prev *ALBIngress) {
+	if prev == nil || len(a.LoadBalancers) == 0 || len(prev.LoadBalancers) == 0 {
+		return
+	}
+	a.LoadBalancers[0].adopt(prev.LoadBalancers[0])
+}
+
+// NewALBIngressFromLoadBalancer builds a minimal ALBIngress instance out of an existing AWS ALB,
+// used when assembling the known-ingress list out of AWS state on controller startup.
+func NewALBIngressFromLoadBalancer(clusterName string, loadBalancer *elbv2.LoadBalancer) (*ALBIngress, bool) {
+	ingressID, ok := tagValue(loadBalancer, "IngressID")
+	if !ok {
+		log.Infof("The LoadBalancer %v does not have an IngressID tag, can't import", "controller", *loadBalancer.LoadBalancerArn)
+		return nil, false
+	}
+
+	// Resources tagged v1 keep their existing, ClusterName-derived names rather than being
+	// renamed to match whatever scheme the controller is currently configured with. Recording the
+	// scheme on the LoadBalancer lets resourceScheme carry it forward into the next
+	// NewALBIngressFromIngress call for this same ingress.
+	scheme := namingSchemeOf(loadBalancerTags(loadBalancer))
+	log.Debugf("Adopted %v under naming scheme %v", "controller", ingressID, scheme)
+
+	lb := NewLoadBalancerFromAWS(loadBalancer)
+	lb.NamingScheme = scheme
+
+	// Discover the ALB's listeners, rules and target group(s), and its security group, so
+	// LoadBalancer.delete() can actually tear all of it down instead of only the ALB itself -
+	// otherwise an Ingress deleted while the controller was down, discovered only via this AWS
+	// tag-based path, would leak its target group(s) and security group permanently.
+	if len(loadBalancer.SecurityGroups) > 0 {
+		lb.SecurityGroup = &SecurityGroup{groupID: loadBalancer.SecurityGroups[0], NamingScheme: scheme}
+	}
+	if listeners, err := discoverListeners(lb.arn); err != nil {
+		log.Errorf("Failed to discover listeners for %v: %v", "controller", ingressID, err)
+	} else {
+		lb.Listeners = listeners
+	}
+
+	return &ALBIngress{
+		id:            ingressID,
+		clusterName:   clusterName,
+		LoadBalancers: LoadBalancersT{lb},
+	}, true
+}
+
+// resolveTargetGroup decides, based on the requested target type, whether targets for the service
+// behind serviceKey should be the service's NodePort (the historical behavior) or the pod IPs
+// backing the service's Endpoints (direct, NodePort-less registration).
+func (ac *ALBController) resolveTargetGroup(serviceKey string, targetType string, backendPort int32, scheme string) (*TargetGroup, error) {
+	parts := strings.SplitN(serviceKey, "/", 2)
+	tgName := ac.resourceName("tg", parts[0], parts[len(parts)-1], scheme)
+
+	if targetType == targetTypeIP {
+		targets, err := ac.GetServiceEndpoints(serviceKey, backendPort)
+		if err != nil {
+			return nil, err
+		}
+		return NewTargetGroup(targetTypeIP, tgName, scheme, targets), nil
+	}
+
+	nodePort, err := ac.GetServiceNodePort(serviceKey, backendPort)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceIDs, err := ac.nodeInstanceIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(instanceIDs))
+	for _, id := range instanceIDs {
+		targets = append(targets, Target{ID: id, Port: *nodePort})
+	}
+	return NewTargetGroup(targetTypeInstance, tgName, scheme, targets), nil
+}
+
+// Reconcile syncs the state of every LoadBalancer owned by this ALBIngress against AWS. Once a
+// deleting ALBIngress has successfully torn down every LoadBalancer, its finalizer is removed so
+// Kubernetes can finish garbage collecting the Ingress resource. The finalizer is left in place if
+// any LoadBalancer fails to tear down, so the next Reconcile() retries the teardown instead of
+// Kubernetes deleting the Ingress out from under a dangling ALB.
+func (a *ALBIngress) Reconcile() error {
+	for _, lb := range a.LoadBalancers {
+		if err := lb.Reconcile(); err != nil {
+			return fmt.Errorf("reconciling load balancer for %v: %v", a.id, err)
+		}
+	}
+
+	if a.deleting && a.ac != nil {
+		return a.ac.removeFinalizer(a.namespace, a.ingressName)
+	}
+
+	return nil
+}
+
+// StripDesiredState removes the desired state from every LoadBalancer owned by this ALBIngress so
+// that the next Reconcile() tears down all AWS resources and removes the finalizer.
+func (a *ALBIngress) StripDesiredState() {
+	a.deleting = true
+	for _, lb := range a.LoadBalancers {
+		lb.StripDesiredState()
+	}
+}
+
+// ALBIngressesT is a list of ALBIngress instances.
+type ALBIngressesT []*ALBIngress
+
+// find returns the position of ingress within the list, or -1 when it isn't present.
+func (ingresses ALBIngressesT) find(ingress *ALBIngress) int {
+	for p, v := range ingresses {
+		if v.id == ingress.id {
+			return p
+		}
+	}
+	return -1
+}
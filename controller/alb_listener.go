@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// Rule represents a single host/path routing rule attached to a Listener, forwarding requests
+// matching Host/Path to TargetGroup.
+type Rule struct {
+	Host        string
+	Path        string
+	TargetGroup *TargetGroup
+	arn         *string
+}
+
+// Listener represents the desired and current state of a single ALB listener and the rules
+// attached to it.
+type Listener struct {
+	Port  int64
+	arn   *string
+	Rules []*Rule
+}
+
+// NewListener returns a Listener for port, with no rules attached yet. The caller is expected to
+// populate Rules before the first Reconcile().
+func NewListener(port int64) *Listener {
+	return &Listener{Port: port}
+}
+
+// Reconcile creates the listener under lbArn if necessary, then reconciles every attached rule's
+// target group and forwarding condition.
+func (l *Listener) Reconcile(lbArn *string) error {
+	if len(l.Rules) == 0 {
+		return fmt.Errorf("cannot reconcile a listener with no rules")
+	}
+
+	// AWS requires a listener's default action on creation; the first rule's target group doubles
+	// as that default, so it must exist before the listener does.
+	if err := l.Rules[0].TargetGroup.Reconcile(); err != nil {
+		return err
+	}
+
+	if l.arn == nil {
+		if err := l.create(lbArn); err != nil {
+			return err
+		}
+	}
+
+	for priority, rule := range l.Rules {
+		if err := rule.Reconcile(l.arn, priority+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delete tears down every rule attached to the listener, then the listener itself.
+func (l *Listener) delete() error {
+	for _, rule := range l.Rules {
+		if err := rule.delete(); err != nil {
+			return err
+		}
+	}
+
+	if l.arn == nil {
+		return nil
+	}
+
+	if _, err := awsutil.ALBsvc.DeleteListener(&elbv2.DeleteListenerInput{ListenerArn: l.arn}); err != nil {
+		return err
+	}
+
+	log.Infof("Deleted listener %v", "controller", *l.arn)
+	l.arn = nil
+	return nil
+}
+
+func (l *Listener) create(lbArn *string) error {
+	o, err := awsutil.ALBsvc.CreateListener(&elbv2.CreateListenerInput{
+		LoadBalancerArn: lbArn,
+		Port:            aws.Int64(l.Port),
+		Protocol:        aws.String("HTTP"),
+		DefaultActions: []*elbv2.Action{
+			{
+				Type:           aws.String("forward"),
+				TargetGroupArn: l.Rules[0].TargetGroup.arn,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	l.arn = o.Listeners[0].ListenerArn
+
+	log.Infof("Created listener %v on port %v", "controller", *l.arn, l.Port)
+	return nil
+}
+
+// Reconcile ensures r's target group exists, then creates the forwarding rule itself under
+// listenerArn at the given priority.
+func (r *Rule) Reconcile(listenerArn *string, priority int) error {
+	if err := r.TargetGroup.Reconcile(); err != nil {
+		return err
+	}
+
+	if r.arn == nil {
+		return r.create(listenerArn, priority)
+	}
+
+	return nil
+}
+
+// delete tears down the forwarding rule, then the target group behind it.
+func (r *Rule) delete() error {
+	if r.arn != nil {
+		if _, err := awsutil.ALBsvc.DeleteRule(&elbv2.DeleteRuleInput{RuleArn: r.arn}); err != nil {
+			return err
+		}
+		log.Infof("Deleted rule %v", "controller", *r.arn)
+		r.arn = nil
+	}
+
+	if r.TargetGroup != nil {
+		return r.TargetGroup.delete()
+	}
+	return nil
+}
+
+func (r *Rule) create(listenerArn *string, priority int) error {
+	conditions := []*elbv2.RuleCondition{
+		{
+			Field:  aws.String("path-pattern"),
+			Values: []*string{aws.String(r.pathPattern())},
+		},
+	}
+	if r.Host != "" {
+		conditions = append(conditions, &elbv2.RuleCondition{
+			Field:  aws.String("host-header"),
+			Values: []*string{aws.String(r.Host)},
+		})
+	}
+
+	o, err := awsutil.ALBsvc.CreateRule(&elbv2.CreateRuleInput{
+		ListenerArn: listenerArn,
+		Priority:    aws.Int64(int64(priority)),
+		Conditions:  conditions,
+		Actions: []*elbv2.Action{
+			{
+				Type:           aws.String("forward"),
+				TargetGroupArn: r.TargetGroup.arn,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	r.arn = o.Rules[0].RuleArn
+
+	log.Infof("Created rule %v", "controller", *r.arn)
+	return nil
+}
+
+func (r *Rule) pathPattern() string {
+	if r.Path == "" {
+		return "/*"
+	}
+	return r.Path
+}
+
+// ruleKey identifies a Rule by its routing condition, the only thing about it that's stable
+// across OnUpdate invocations (its arn and target group are rebuilt from scratch every time).
+type ruleKey struct {
+	host string
+	path string
+}
+
+// adopt copies forward prev's AWS-assigned arn onto l, then adopts each of l's rules from the rule
+// in prev with the same host/path, so a listener whose rule set didn't change doesn't try to
+// recreate the listener or any of its rules/target groups.
+func (l *Listener) adopt(prev *Listener) {
+	l.arn = prev.arn
+
+	prevRules := make(map[ruleKey]*Rule, len(prev.Rules))
+	for _, r := range prev.Rules {
+		prevRules[ruleKey{r.Host, r.Path}] = r
+	}
+	for _, rule := range l.Rules {
+		if prevRule, ok := prevRules[ruleKey{rule.Host, rule.Path}]; ok {
+			rule.adopt(prevRule)
+		}
+	}
+}
+
+// adopt copies forward prev's AWS-assigned arn, and its target group's arn and current targets,
+// onto r.
+func (r *Rule) adopt(prev *Rule) {
+	r.arn = prev.arn
+	if r.TargetGroup != nil && prev.TargetGroup != nil {
+		r.TargetGroup.adopt(prev.TargetGroup)
+	}
+}
+
+// discoverListeners rebuilds the Listener/Rule/TargetGroup tree already attached to an existing
+// ALB by describing it in AWS, so assembleIngresses can adopt a pre-existing load balancer's child
+// resources instead of losing track of everything but the ALB's own arn - which left LoadBalancer
+// delete() leaking the target group(s) and security group of any ALB discovered this way.
+func discoverListeners(lbArn *string) ([]*Listener, error) {
+	o, err := awsutil.ALBsvc.DescribeListeners(&elbv2.DescribeListenersInput{LoadBalancerArn: lbArn})
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]*Listener, 0, len(o.Listeners))
+	for _, awsListener := range o.Listeners {
+		rules, err := discoverRules(awsListener.ListenerArn)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, &Listener{
+			Port:  aws.Int64Value(awsListener.Port),
+			arn:   awsListener.ListenerArn,
+			Rules: rules,
+		})
+	}
+	return listeners, nil
+}
+
+// discoverRules describes the non-default rules attached to listenerArn (the default rule isn't
+// modeled as a Rule; it's implied by the listener's DefaultActions) and the target group each one
+// forwards to.
+func discoverRules(listenerArn *string) ([]*Rule, error) {
+	o, err := awsutil.ALBsvc.DescribeRules(&elbv2.DescribeRulesInput{ListenerArn: listenerArn})
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	for _, awsRule := range o.Rules {
+		if aws.BoolValue(awsRule.IsDefault) || len(awsRule.Actions) == 0 {
+			continue
+		}
+
+		targetGroup, err := discoverTargetGroup(awsRule.Actions[0].TargetGroupArn)
+		if err != nil {
+			return nil, err
+		}
+
+		rule := &Rule{TargetGroup: targetGroup, arn: awsRule.RuleArn}
+		for _, cond := range awsRule.Conditions {
+			if len(cond.Values) == 0 {
+				continue
+			}
+			switch aws.StringValue(cond.Field) {
+			case "host-header":
+				rule.Host = aws.StringValue(cond.Values[0])
+			case "path-pattern":
+				if path := aws.StringValue(cond.Values[0]); path != "/*" {
+					rule.Path = path
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// LoadBalancer represents the desired and current state of a single ALB, its security group, and
+// the listeners routing traffic to it.
+type LoadBalancer struct {
+	clusterName   string
+	ingressID     string
+	Name          string
+	NamingScheme  string
+	arn           *string
+	Listeners     []*Listener
+	SecurityGroup *SecurityGroup
+	deleted       bool
+}
+
+// LoadBalancersT is a list of LoadBalancer instances.
+type LoadBalancersT []*LoadBalancer
+
+// NewLoadBalancer returns a LoadBalancer with no listeners attached yet. The caller is expected to
+// populate Listeners and SecurityGroup before the first Reconcile(). name and namingScheme come
+// from ALBController.resourceName so v1 and v2 resources can coexist.
+func NewLoadBalancer(clusterName, ingressID, name, namingScheme string) *LoadBalancer {
+	return &LoadBalancer{
+		clusterName:  clusterName,
+		ingressID:    ingressID,
+		Name:         name,
+		NamingScheme: namingScheme,
+	}
+}
+
+// NewLoadBalancerFromAWS wraps an existing ALB discovered in AWS, used when rebuilding controller
+// state from existing resources rather than from the Kubernetes ingress list.
+func NewLoadBalancerFromAWS(loadBalancer *elbv2.LoadBalancer) *LoadBalancer {
+	return &LoadBalancer{
+		arn: loadBalancer.LoadBalancerArn,
+	}
+}
+
+// Reconcile creates or updates the ALB, its security group, and every listener (and the rules and
+// target groups attached to them) to match the desired state.
+func (lb *LoadBalancer) Reconcile() error {
+	if lb.deleted {
+		return lb.delete()
+	}
+
+	if lb.SecurityGroup != nil {
+		if err := lb.SecurityGroup.Reconcile(); err != nil {
+			return err
+		}
+	}
+
+	if lb.arn == nil {
+		if err := lb.create(); err != nil {
+			return err
+		}
+	}
+
+	for _, listener := range lb.Listeners {
+		if err := listener.Reconcile(lb.arn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// create provisions the ALB itself. The security group must already be reconciled so its groupID
+// can be attached.
+func (lb *LoadBalancer) create() error {
+	if lb.SecurityGroup == nil {
+		return fmt.Errorf("cannot create load balancer %v without a security group", lb.ingressID)
+	}
+
+	o, err := awsutil.ALBsvc.CreateLoadBalancer(&elbv2.CreateLoadBalancerInput{
+		Name:           aws.String(lb.Name),
+		Scheme:         aws.String("internet-facing"),
+		SecurityGroups: []*string{lb.SecurityGroup.groupID},
+	})
+	if err != nil {
+		return err
+	}
+	lb.arn = o.LoadBalancers[0].LoadBalancerArn
+
+	if _, err := awsutil.ALBsvc.AddTags(&elbv2.AddTagsInput{
+		ResourceArns: []*string{lb.arn},
+		Tags: []*elbv2.Tag{
+			{Key: aws.String("IngressID"), Value: aws.String(lb.ingressID)},
+			{Key: aws.String(resourceNamingSchemeTag), Value: aws.String(lb.NamingScheme)},
+		},
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("Created load balancer %v", "controller", *lb.arn)
+	return nil
+}
+
+// adopt copies forward prev's AWS-assigned identifiers onto lb - the ALB arn, the security group's
+// groupID, and each listener/rule/target group's arn - so Reconcile() updates the AWS resources
+// that already exist instead of calling Create* on them again. Listeners are matched by port and
+// rules by host/path, since those are the only stable identity either has across OnUpdate
+// invocations; anything newly added has no match and is created as usual.
+func (lb *LoadBalancer) adopt(prev *LoadBalancer) {
+	if prev == nil {
+		return
+	}
+
+	lb.arn = prev.arn
+
+	if lb.SecurityGroup != nil && prev.SecurityGroup != nil {
+		lb.SecurityGroup.adopt(prev.SecurityGroup)
+	}
+
+	prevListeners := make(map[int64]*Listener, len(prev.Listeners))
+	for _, l := range prev.Listeners {
+		prevListeners[l.Port] = l
+	}
+	for _, listener := range lb.Listeners {
+		if prevListener, ok := prevListeners[listener.Port]; ok {
+			listener.adopt(prevListener)
+		}
+	}
+}
+
+// StripDesiredState marks the LoadBalancer for deletion on the next Reconcile(). Listeners and
+// SecurityGroup are left in place so delete() still knows what to tear down.
+func (lb *LoadBalancer) StripDesiredState() {
+	lb.deleted = true
+}
+
+// delete tears down every listener (and the rules/target groups attached to them), then the ALB
+// itself, then its security group - the order AWS requires, since each resource can only be
+// deleted once nothing still references it.
+func (lb *LoadBalancer) delete() error {
+	for _, listener := range lb.Listeners {
+		if err := listener.delete(); err != nil {
+			return err
+		}
+	}
+
+	if lb.arn != nil {
+		if _, err := awsutil.ALBsvc.DeleteLoadBalancer(&elbv2.DeleteLoadBalancerInput{
+			LoadBalancerArn: lb.arn,
+		}); err != nil {
+			return err
+		}
+		log.Infof("Deleted load balancer %v", "controller", *lb.arn)
+		lb.arn = nil
+	}
+
+	if lb.SecurityGroup != nil {
+		if err := lb.SecurityGroup.delete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tagValue returns the value of the named tag on the load balancer, if present.
+func tagValue(loadBalancer *elbv2.LoadBalancer, key string) (string, bool) {
+	tags := loadBalancerTags(loadBalancer)
+	v, ok := tags[key]
+	return v, ok
+}
+
+func loadBalancerTags(loadBalancer *elbv2.LoadBalancer) map[string]string {
+	tags := make(map[string]string, len(loadBalancer.Tags))
+	for _, tag := range loadBalancer.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	return tags
+}
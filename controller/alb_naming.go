@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// namingSchemeV1 is the original, ClusterName-derived naming scheme. Because an AWS resource
+	// name has a fixed length budget, it forces ClusterName to stay short (11 characters or less).
+	namingSchemeV1 = "v1"
+
+	// namingSchemeV2 derives the unique part of a resource's name from a hash of the kube-system
+	// namespace UID instead of from ClusterName, so ClusterName no longer needs to be short and
+	// names stay unique even across clusters that share a name.
+	namingSchemeV2 = "v2"
+
+	// resourceNamingSchemeTag records which scheme produced a resource, so assembleIngresses can
+	// adopt pre-existing v1 resources by name while everything new is created under v2.
+	resourceNamingSchemeTag = "elbv2.io/resource-naming-scheme"
+
+	// maxResourceNameLength is AWS's hard limit on ALB/target-group/security-group names.
+	maxResourceNameLength = 32
+)
+
+// UsesV2Naming reports whether the controller was configured with --resource-naming-scheme=v2.
+func (ac *ALBController) UsesV2Naming() bool {
+	return ac.namingScheme != nil && *ac.namingScheme == namingSchemeV2
+}
+
+// resourceScheme returns the naming scheme to generate new resource names for this ingress under:
+// the controller-wide --resource-naming-scheme flag, unless an AWS resource already exists for
+// this ingress under a different scheme (prev is its ALBIngress from the previous OnUpdate
+// snapshot, or nil), in which case that scheme is kept so the resource isn't renamed - and
+// therefore recreated under the new name - out from under itself.
+func (ac *ALBController) resourceScheme(prev *ALBIngress) string {
+	scheme := namingSchemeV1
+	if ac.UsesV2Naming() {
+		scheme = namingSchemeV2
+	}
+	if prev != nil && len(prev.LoadBalancers) > 0 && prev.LoadBalancers[0].NamingScheme != "" {
+		scheme = prev.LoadBalancers[0].NamingScheme
+	}
+	return scheme
+}
+
+// resourceName returns the AWS resource name to use for the given kind ("lb", "tg", "sg"), owning
+// namespace/name (the ingress's for "lb" and "sg", the backend service's for "tg"), and naming
+// scheme, bounded to maxResourceNameLength since AWS rejects longer names outright.
+func (ac *ALBController) resourceName(kind, namespace, name, scheme string) string {
+	var raw string
+	if scheme == namingSchemeV2 {
+		raw = fmt.Sprintf("%s-%s-%s", *ac.ClusterName, kind, ac.v2Suffix(namespace, name))
+	} else {
+		raw = fmt.Sprintf("%.11s-%s-%s", *ac.ClusterName, kind, name)
+	}
+	return truncateName(raw)
+}
+
+// truncateName bounds name to maxResourceNameLength. Names over the limit have their tail replaced
+// with a short hash of the full name rather than simply being cut off, so two names that happen to
+// share a long prefix (e.g. the same over-length ClusterName) don't collide once truncated.
+func truncateName(name string) string {
+	if len(name) <= maxResourceNameLength {
+		return name
+	}
+
+	h := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(h[:])[:8]
+	return name[:maxResourceNameLength-len(suffix)] + suffix
+}
+
+// v2Suffix hashes the kube-system namespace UID together with the cluster name and the owning
+// namespace/name passed to resourceName down to a short, deterministic, collision-resistant suffix.
+func (ac *ALBController) v2Suffix(namespace, name string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s", ac.clusterUID, *ac.ClusterName, namespace, name)))
+	return hex.EncodeToString(h[:])[:10]
+}
+
+// namingSchemeOf returns the scheme ("v1" or "v2") that produced an already-existing AWS resource,
+// read back from its resourceNamingSchemeTag. Resources created before this tag existed are
+// treated as v1.
+func namingSchemeOf(tags map[string]string) string {
+	if scheme, ok := tags[resourceNamingSchemeTag]; ok {
+		return scheme
+	}
+	return namingSchemeV1
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func newTestController(clusterName string) *ALBController {
+	return &ALBController{
+		ClusterName: aws.String(clusterName),
+		clusterUID:  "test-uid",
+	}
+}
+
+func TestResourceNameV1BoundsLongClusterAndServiceName(t *testing.T) {
+	ac := newTestController("a-very-long-cluster-name-indeed")
+	name := ac.resourceName("tg", "default", strings.Repeat("backend-service", 3), namingSchemeV1)
+	if len(name) > maxResourceNameLength {
+		t.Fatalf("resourceName produced %q (%d chars), want <= %d", name, len(name), maxResourceNameLength)
+	}
+}
+
+func TestResourceNameV2BoundsLongClusterName(t *testing.T) {
+	ac := newTestController(strings.Repeat("long-cluster-name", 5))
+	name := ac.resourceName("lb", "default", "my-ingress", namingSchemeV2)
+	if len(name) > maxResourceNameLength {
+		t.Fatalf("resourceName produced %q (%d chars), want <= %d", name, len(name), maxResourceNameLength)
+	}
+}
+
+func TestResourceNameDeterministic(t *testing.T) {
+	ac := newTestController("prod")
+	a := ac.resourceName("tg", "default", "svc", namingSchemeV2)
+	b := ac.resourceName("tg", "default", "svc", namingSchemeV2)
+	if a != b {
+		t.Errorf("resourceName should be deterministic, got %q and %q", a, b)
+	}
+}
+
+func TestResourceSchemeReusesPriorScheme(t *testing.T) {
+	ac := newTestController("prod")
+	ac.namingScheme = aws.String(namingSchemeV2)
+
+	prev := &ALBIngress{
+		LoadBalancers: LoadBalancersT{&LoadBalancer{NamingScheme: namingSchemeV1}},
+	}
+
+	if got := ac.resourceScheme(prev); got != namingSchemeV1 {
+		t.Errorf("expected to reuse the prior v1 scheme, got %v", got)
+	}
+	if got := ac.resourceScheme(nil); got != namingSchemeV2 {
+		t.Errorf("expected a never-before-seen ingress to use the configured v2 scheme, got %v", got)
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// defaultInboundCIDR is used when an Ingress doesn't restrict inbound traffic, matching the
+// previous, unconditional behavior.
+const defaultInboundCIDR = "0.0.0.0/0"
+
+// SecurityGroup represents the desired and current state of the security group ALB uses to
+// control what can reach its listeners.
+type SecurityGroup struct {
+	groupID      *string
+	ports        []int64
+	Name         string
+	NamingScheme string
+	DesiredCIDRs []string
+	CurrentCIDRs []string
+}
+
+// NewSecurityGroup returns a SecurityGroup restricting the given listener ports to desiredCIDRs.
+// name and namingScheme come from ALBController.resourceName so v1 and v2 resources can coexist.
+func NewSecurityGroup(ports []int64, name, namingScheme string, desiredCIDRs []string) *SecurityGroup {
+	return &SecurityGroup{
+		ports:        ports,
+		Name:         name,
+		NamingScheme: namingScheme,
+		DesiredCIDRs: desiredCIDRs,
+	}
+}
+
+// parseInboundCIDRs resolves the CIDR blocks that should be allowed to reach an Ingress's
+// listeners. extensions/v1beta1 Ingress has no spec.loadBalancerSourceRanges field (unlike
+// Service), so the inbound-cidrs annotation is the equivalent for this controller. Absent either,
+// the security group stays open to the world as before.
+func parseInboundCIDRs(annotations map[string]string) ([]string, error) {
+	raw, ok := annotations[ingressAnnotationInboundCIDRs]
+	if !ok || raw == "" {
+		return []string{defaultInboundCIDR}, nil
+	}
+
+	var cidrs []string
+	for _, c := range strings.Split(raw, ",") {
+		c = strings.TrimSpace(c)
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, c)
+	}
+	return cidrs, nil
+}
+
+// Reconcile creates the security group if necessary, then diffs CurrentCIDRs against
+// DesiredCIDRs for each listener port, authorizing and revoking only what changed.
+func (sg *SecurityGroup) Reconcile() error {
+	if sg.groupID == nil {
+		if err := sg.create(); err != nil {
+			return err
+		}
+	}
+
+	add, remove := cidrDiff(sg.CurrentCIDRs, sg.DesiredCIDRs)
+
+	for _, port := range sg.ports {
+		if len(add) > 0 {
+			if _, err := awsutil.Ec2svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+				GroupId:       sg.groupID,
+				IpPermissions: ipPermissions(port, add),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if len(remove) > 0 {
+			if _, err := awsutil.Ec2svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       sg.groupID,
+				IpPermissions: ipPermissions(port, remove),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	sg.CurrentCIDRs = sg.DesiredCIDRs
+	return nil
+}
+
+func (sg *SecurityGroup) create() error {
+	o, err := awsutil.Ec2svc.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		Description: aws.String("Managed by alb-ingress-controller"),
+		GroupName:   aws.String(sg.Name),
+	})
+	if err != nil {
+		return err
+	}
+	sg.groupID = o.GroupId
+
+	if _, err := awsutil.Ec2svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{sg.groupID},
+		Tags:      []*ec2.Tag{{Key: aws.String(resourceNamingSchemeTag), Value: aws.String(sg.NamingScheme)}},
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("Created security group %v", "controller", *sg.groupID)
+	return nil
+}
+
+// adopt copies forward prev's AWS-assigned groupID and already-authorized CIDRs onto sg, so
+// Reconcile() diffs against what's really in AWS instead of trying to create a security group that
+// already exists.
+func (sg *SecurityGroup) adopt(prev *SecurityGroup) {
+	sg.groupID = prev.groupID
+	sg.CurrentCIDRs = prev.CurrentCIDRs
+}
+
+// delete removes the security group from AWS. It must only be called after the load balancer
+// referencing it has already been deleted.
+func (sg *SecurityGroup) delete() error {
+	if sg.groupID == nil {
+		return nil
+	}
+
+	if _, err := awsutil.Ec2svc.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: sg.groupID,
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("Deleted security group %v", "controller", *sg.groupID)
+	sg.groupID = nil
+	return nil
+}
+
+// cidrDiff returns the CIDRs present in desired but not current (to add) and the CIDRs present in
+// current but not desired (to remove).
+func cidrDiff(current, desired []string) (add, remove []string) {
+	currentSet := make(map[string]bool, len(current))
+	for _, c := range current {
+		currentSet[c] = true
+	}
+	desiredSet := make(map[string]bool, len(desired))
+	for _, c := range desired {
+		desiredSet[c] = true
+	}
+
+	for _, c := range desired {
+		if !currentSet[c] {
+			add = append(add, c)
+		}
+	}
+	for _, c := range current {
+		if !desiredSet[c] {
+			remove = append(remove, c)
+		}
+	}
+	return add, remove
+}
+
+func ipPermissions(port int64, cidrs []string) []*ec2.IpPermission {
+	var ranges []*ec2.IpRange
+	for _, c := range cidrs {
+		ranges = append(ranges, &ec2.IpRange{CidrIp: aws.String(c)})
+	}
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(port),
+			ToPort:     aws.Int64(port),
+			IpRanges:   ranges,
+		},
+	}
+}
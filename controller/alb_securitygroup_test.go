@@ -0,0 +1,52 @@
+package controller
+
+import "testing"
+
+func TestParseInboundCIDRsDefaultsToOpen(t *testing.T) {
+	cidrs, err := parseInboundCIDRs(map[string]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cidrs) != 1 || cidrs[0] != defaultInboundCIDR {
+		t.Errorf("expected default-open CIDR, got %v", cidrs)
+	}
+}
+
+func TestParseInboundCIDRsParsesList(t *testing.T) {
+	cidrs, err := parseInboundCIDRs(map[string]string{
+		ingressAnnotationInboundCIDRs: "10.0.0.0/8, 192.168.1.0/24",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.0/24"}
+	if len(cidrs) != len(want) {
+		t.Fatalf("expected %v, got %v", want, cidrs)
+	}
+	for i := range want {
+		if cidrs[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, cidrs)
+		}
+	}
+}
+
+func TestParseInboundCIDRsRejectsMalformed(t *testing.T) {
+	if _, err := parseInboundCIDRs(map[string]string{
+		ingressAnnotationInboundCIDRs: "not-a-cidr",
+	}); err == nil {
+		t.Error("expected an error for a malformed CIDR, got nil")
+	}
+}
+
+func TestCidrDiff(t *testing.T) {
+	add, remove := cidrDiff(
+		[]string{"10.0.0.0/8", "172.16.0.0/12"},
+		[]string{"10.0.0.0/8", "192.168.0.0/16"},
+	)
+	if len(add) != 1 || add[0] != "192.168.0.0/16" {
+		t.Errorf("expected to add [192.168.0.0/16], got %v", add)
+	}
+	if len(remove) != 1 || remove[0] != "172.16.0.0/12" {
+		t.Errorf("expected to remove [172.16.0.0/12], got %v", remove)
+	}
+}
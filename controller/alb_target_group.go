@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// Target is a single registration target behind a TargetGroup. For the "instance" target type, ID
+// holds a node's instance ID and Port its NodePort. For the "ip" target type, ID holds a pod IP
+// and Port its containerPort.
+type Target struct {
+	ID   string
+	Port int64
+}
+
+// TargetGroup represents the desired and current state of a single ALB target group.
+type TargetGroup struct {
+	TargetType     string
+	Name           string
+	NamingScheme   string
+	arn            *string
+	DesiredTargets []Target
+	CurrentTargets []Target
+}
+
+// NewTargetGroup returns a TargetGroup with the desired target list populated. CurrentTargets is
+// filled in once the target group has been reconciled against AWS at least once. name and
+// namingScheme come from ALBController.resourceName so v1 and v2 resources can coexist.
+func NewTargetGroup(targetType, name, namingScheme string, targets []Target) *TargetGroup {
+	return &TargetGroup{
+		TargetType:     targetType,
+		Name:           name,
+		NamingScheme:   namingScheme,
+		DesiredTargets: targets,
+	}
+}
+
+// Reconcile creates the target group in AWS if necessary, then diffs CurrentTargets against
+// DesiredTargets, registering and deregistering only what changed instead of re-registering the
+// full list on every sync.
+func (tg *TargetGroup) Reconcile() error {
+	if tg.arn == nil {
+		if err := tg.create(); err != nil {
+			return err
+		}
+	}
+
+	add, remove := targetDiff(tg.CurrentTargets, tg.DesiredTargets)
+
+	if len(add) > 0 {
+		if _, err := awsutil.ALBsvc.RegisterTargets(&elbv2.RegisterTargetsInput{
+			TargetGroupArn: tg.arn,
+			Targets:        targetDescriptions(add),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 {
+		if _, err := awsutil.ALBsvc.DeregisterTargets(&elbv2.DeregisterTargetsInput{
+			TargetGroupArn: tg.arn,
+			Targets:        targetDescriptions(remove),
+		}); err != nil {
+			return err
+		}
+	}
+
+	tg.CurrentTargets = tg.DesiredTargets
+	return nil
+}
+
+// create provisions the target group itself, setting TargetType so AWS knows whether to expect
+// instance IDs or IP addresses in subsequent Register/DeregisterTargets calls.
+func (tg *TargetGroup) create() error {
+	o, err := awsutil.ALBsvc.CreateTargetGroup(&elbv2.CreateTargetGroupInput{
+		Name:       aws.String(tg.Name),
+		TargetType: aws.String(tg.TargetType),
+	})
+	if err != nil {
+		return err
+	}
+	tg.arn = o.TargetGroups[0].TargetGroupArn
+
+	if _, err := awsutil.ALBsvc.AddTags(&elbv2.AddTagsInput{
+		ResourceArns: []*string{tg.arn},
+		Tags:         []*elbv2.Tag{{Key: aws.String(resourceNamingSchemeTag), Value: aws.String(tg.NamingScheme)}},
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("Created target group %v of type %v", "controller", *tg.arn, tg.TargetType)
+	return nil
+}
+
+// adopt copies forward prev's AWS-assigned arn and already-registered targets onto tg, so
+// Reconcile() diffs against what's really in AWS instead of trying to create a target group that
+// already exists.
+func (tg *TargetGroup) adopt(prev *TargetGroup) {
+	tg.arn = prev.arn
+	tg.CurrentTargets = prev.CurrentTargets
+}
+
+// discoverTargetGroup describes an existing target group and its registered targets, used by
+// discoverRules to rebuild the TargetGroup a rule discovered from AWS forwards to.
+func discoverTargetGroup(tgArn *string) (*TargetGroup, error) {
+	o, err := awsutil.ALBsvc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{TargetGroupArns: []*string{tgArn}})
+	if err != nil {
+		return nil, err
+	}
+	if len(o.TargetGroups) == 0 {
+		return nil, fmt.Errorf("target group %v not found", *tgArn)
+	}
+
+	health, err := awsutil.ALBsvc.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: tgArn})
+	if err != nil {
+		return nil, err
+	}
+
+	currentTargets := make([]Target, 0, len(health.TargetHealthDescriptions))
+	for _, h := range health.TargetHealthDescriptions {
+		currentTargets = append(currentTargets, Target{
+			ID:   aws.StringValue(h.Target.Id),
+			Port: aws.Int64Value(h.Target.Port),
+		})
+	}
+
+	return &TargetGroup{
+		TargetType:     aws.StringValue(o.TargetGroups[0].TargetType),
+		arn:            tgArn,
+		CurrentTargets: currentTargets,
+	}, nil
+}
+
+// delete removes the target group from AWS. It must only be called after the rule(s) forwarding
+// to it have already been deleted, since AWS refuses to delete a target group still referenced by
+// a listener rule.
+func (tg *TargetGroup) delete() error {
+	if tg.arn == nil {
+		return nil
+	}
+
+	if _, err := awsutil.ALBsvc.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{
+		TargetGroupArn: tg.arn,
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("Deleted target group %v", "controller", *tg.arn)
+	tg.arn = nil
+	return nil
+}
+
+// targetDiff returns the targets present in desired but not current (to add) and the targets
+// present in current but not desired (to remove).
+func targetDiff(current, desired []Target) (add, remove []Target) {
+	currentSet := make(map[Target]bool, len(current))
+	for _, t := range current {
+		currentSet[t] = true
+	}
+	desiredSet := make(map[Target]bool, len(desired))
+	for _, t := range desired {
+		desiredSet[t] = true
+	}
+
+	for _, t := range desired {
+		if !currentSet[t] {
+			add = append(add, t)
+		}
+	}
+	for _, t := range current {
+		if !desiredSet[t] {
+			remove = append(remove, t)
+		}
+	}
+	return add, remove
+}
+
+func targetDescriptions(targets []Target) []*elbv2.TargetDescription {
+	var out []*elbv2.TargetDescription
+	for _, t := range targets {
+		out = append(out, &elbv2.TargetDescription{
+			Id:   aws.String(t.ID),
+			Port: aws.Int64(t.Port),
+		})
+	}
+	return out
+}
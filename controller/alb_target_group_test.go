@@ -0,0 +1,38 @@
+package controller
+
+import "testing"
+
+func TestTargetDiff(t *testing.T) {
+	current := []Target{{ID: "i-1", Port: 30000}, {ID: "i-2", Port: 30000}}
+	desired := []Target{{ID: "i-2", Port: 30000}, {ID: "i-3", Port: 30000}}
+
+	add, remove := targetDiff(current, desired)
+
+	if len(add) != 1 || add[0] != (Target{ID: "i-3", Port: 30000}) {
+		t.Errorf("expected to add [i-3], got %v", add)
+	}
+	if len(remove) != 1 || remove[0] != (Target{ID: "i-1", Port: 30000}) {
+		t.Errorf("expected to remove [i-1], got %v", remove)
+	}
+}
+
+func TestTargetDiffNoChange(t *testing.T) {
+	targets := []Target{{ID: "i-1", Port: 30000}}
+	add, remove := targetDiff(targets, targets)
+	if len(add) != 0 || len(remove) != 0 {
+		t.Errorf("expected no diff, got add=%v remove=%v", add, remove)
+	}
+}
+
+func TestTargetDiffDistinguishesPort(t *testing.T) {
+	add, remove := targetDiff(
+		[]Target{{ID: "i-1", Port: 30000}},
+		[]Target{{ID: "i-1", Port: 30001}},
+	)
+	if len(add) != 1 || add[0].Port != 30001 {
+		t.Errorf("expected to add port 30001, got %v", add)
+	}
+	if len(remove) != 1 || remove[0].Port != 30000 {
+		t.Errorf("expected to remove port 30000, got %v", remove)
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// defaultReconcileWorkers is used when --reconcile-workers isn't set.
+const defaultReconcileWorkers = 4
+
+// throttlingErrorCodes are the aws-sdk-go error codes ELBv2/EC2 return when we're being
+// rate-limited. Failures with these codes are logged as throttling rather than as errors; like
+// every other failed sync, they are requeued through the rate limiter's backoff.
+var throttlingErrorCodes = map[string]bool{
+	"RequestLimitExceeded": true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+}
+
+func isThrottlingError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return throttlingErrorCodes[awsErr.Code()]
+	}
+	return false
+}
+
+func newReconcileQueue() workqueue.RateLimitingInterface {
+	return workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "alb-ingress")
+}
+
+// reconcileWorkerCount returns the configured worker pool size, falling back to
+// defaultReconcileWorkers before --reconcile-workers has been parsed.
+func (ac *ALBController) reconcileWorkerCount() int {
+	if ac.reconcileWorkers == nil || *ac.reconcileWorkers <= 0 {
+		return defaultReconcileWorkers
+	}
+	return *ac.reconcileWorkers
+}
+
+// runReconcileWorkers starts the bounded worker pool draining ac.workqueue. It replaces the
+// unbounded per-ALBIngress goroutine fan-out Reload used to do.
+func (ac *ALBController) runReconcileWorkers() {
+	for i := 0; i < ac.reconcileWorkerCount(); i++ {
+		go func() {
+			for ac.processNextWorkItem() {
+			}
+		}()
+	}
+}
+
+// processNextWorkItem pops a single ingress key off the queue and syncs it, requeuing with
+// backoff on error. It returns false once the queue has been shut down.
+func (ac *ALBController) processNextWorkItem() bool {
+	key, shutdown := ac.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer ac.workqueue.Done(key)
+
+	awsutil.QueueDepth.Set(float64(ac.workqueue.Len()))
+
+	start := time.Now()
+	err := ac.syncIngressByKey(key.(string))
+	awsutil.LastSyncDuration.WithLabelValues(key.(string)).Set(time.Since(start).Seconds())
+
+	switch {
+	case err == nil:
+		ac.workqueue.Forget(key)
+	case isThrottlingError(err):
+		awsutil.RetryCount.Add(float64(1))
+		log.Infof("Reconciling %v was throttled, backing off: %v", "controller", key, err)
+		ac.workqueue.AddRateLimited(key)
+	default:
+		awsutil.RetryCount.Add(float64(1))
+		log.Errorf("Failed reconciling %v, will retry: %v", "controller", key, err)
+		ac.workqueue.AddRateLimited(key)
+	}
+
+	return true
+}
+
+// syncIngressByKey looks up the ALBIngress snapshot OnUpdate indexed under key and syncs it.
+// Ingresses slated for deletion are indexed the same way, already stripped of desired state by
+// OnUpdate (directly, or via ingressToDelete), and are routed through GC instead of Sync.
+func (ac *ALBController) syncIngressByKey(key string) error {
+	ac.ingressIndexMu.Lock()
+	i, ok := ac.ingressIndex[key]
+	ac.ingressIndexMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if i.deleting {
+		return ac.activeSyncer().GC(ALBIngressesT{i})
+	}
+
+	return ac.activeSyncer().Sync(i)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	throttled := awserr.New("Throttling", "rate exceeded", nil)
+	if !isThrottlingError(throttled) {
+		t.Error("expected a Throttling awserr to be treated as a throttling error")
+	}
+
+	other := awserr.New("ValidationError", "bad input", nil)
+	if isThrottlingError(other) {
+		t.Error("expected a ValidationError awserr not to be treated as a throttling error")
+	}
+
+	if isThrottlingError(fmt.Errorf("boom")) {
+		t.Error("expected a plain, non-awserr error not to be treated as a throttling error")
+	}
+}
+
+func TestInstanceIDFromProviderID(t *testing.T) {
+	id, err := instanceIDFromProviderID("aws:///us-west-2a/i-0123456789abcdef0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "i-0123456789abcdef0" {
+		t.Errorf("expected i-0123456789abcdef0, got %v", id)
+	}
+}
+
+func TestInstanceIDFromProviderIDRejectsEmpty(t *testing.T) {
+	if _, err := instanceIDFromProviderID(""); err == nil {
+		t.Error("expected an error for an empty providerID, got nil")
+	}
+}
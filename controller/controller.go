@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,16 +17,34 @@ import (
 
 	api "k8s.io/client-go/pkg/api/v1"
 	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/ingress/core/pkg/ingress"
 	"k8s.io/ingress/core/pkg/ingress/defaults"
 )
 
 // ALBController is our main controller
 type ALBController struct {
-	storeLister  ingress.StoreLister
-	ALBIngresses ALBIngressesT
-	ClusterName  *string
-	IngressClass string
+	storeLister      ingress.StoreLister
+	ALBIngresses     ALBIngressesT
+	ClusterName      *string
+	IngressClass     string
+	kubeClient       kubernetes.Interface
+	finalizerRemove  *bool
+	namingScheme     *string
+	clusterUID       string
+	Syncer           Syncer
+	dryRun           *bool
+	reconcileWorkers *int
+	workersOnce      sync.Once
+
+	// workqueue holds the namespace/name of every ALBIngress known to OnUpdate, rate-limited so
+	// AWS API throttling backs off instead of retry-storming. ingressIndex is the snapshot the
+	// queue's keys are resolved against.
+	workqueue      workqueue.RateLimitingInterface
+	ingressIndexMu sync.Mutex
+	ingressIndex   map[string]*ALBIngress
 }
 
 // NewALBController returns an ALBController
@@ -42,6 +61,26 @@ func NewALBController(awsconfig *aws.Config, conf *config.Config) *ALBController
 	awsutil.ACMsvc = awsutil.NewACM(awsutil.Session)
 	awsutil.IAMsvc = awsutil.NewIAM(awsutil.Session)
 
+	kubeConfig, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatalf("Unable to build in-cluster Kubernetes config: %v", err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		glog.Fatalf("Unable to create Kubernetes client: %v", err)
+	}
+	ac.kubeClient = kubeClient
+
+	kubeSystem, err := kubeClient.CoreV1().Namespaces().Get("kube-system", api.GetOptions{})
+	if err != nil {
+		glog.Fatalf("Unable to fetch kube-system namespace: %v", err)
+	}
+	ac.clusterUID = string(kubeSystem.UID)
+
+	ac.Syncer = &awsSyncer{}
+	ac.workqueue = newReconcileQueue()
+	ac.ingressIndex = make(map[string]*ALBIngress)
+
 	return ingress.Controller(ac).(*ALBController)
 }
 
@@ -55,10 +94,24 @@ func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) er
 		ac.assembleIngresses()
 	}
 
+	ac.workersOnce.Do(ac.runReconcileWorkers)
+
 	awsutil.OnUpdateCount.Add(float64(1))
 
 	log.Debugf("OnUpdate event seen by ALB ingress controller.", "controller")
 
+	if ac.finalizerRemove != nil && *ac.finalizerRemove {
+		ac.stripAllFinalizers()
+	}
+
+	// Snapshot the previous cycle's ALBIngresses by ID so already-reconciled AWS resource
+	// identifiers can be carried forward into the freshly-built tree below, keyed the same way
+	// NewALBIngressFromIngress derives an ALBIngress's id.
+	prevByID := make(map[string]*ALBIngress, len(ac.ALBIngresses))
+	for _, i := range ac.ALBIngresses {
+		prevByID[i.id] = i
+	}
+
 	// Create new ALBIngress list for this invocation.
 	var ALBIngresses ALBIngressesT
 	// Find every ingress currently in Kubernetes.
@@ -68,27 +121,66 @@ func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) er
 		if !ac.validIngress(ingResource) {
 			continue
 		}
+
+		ingressID := fmt.Sprintf("%s/%s", ingResource.Namespace, ingResource.Name)
+
+		// An Ingress being deleted still shows up here until our finalizer is removed. Enqueue it
+		// for teardown immediately, even if the controller restarted and never saw it while live.
+		if ingResource.DeletionTimestamp != nil && hasFinalizer(ingResource) {
+			ALBIngress, err := NewALBIngressFromIngress(ingResource, ac, prevByID[ingressID])
+			if ALBIngress == nil {
+				continue
+			}
+			if err != nil {
+				ALBIngress.tainted = true
+			}
+			ALBIngress.StripDesiredState()
+			ALBIngresses = append(ALBIngresses, ALBIngress)
+			continue
+		}
+
 		// Produce a new ALBIngress instance for every ingress found. If ALBIngress returns nil, there
 		// was an issue with the ingress (e.g. bad annotations) and should not be added to the list.
-		ALBIngress, err := NewALBIngressFromIngress(ingResource, ac)
+		ALBIngress, err := NewALBIngressFromIngress(ingResource, ac, prevByID[ingressID])
 		if ALBIngress == nil {
 			continue
 		}
 		if err != nil {
 			ALBIngress.tainted = true
 		}
+
+		if !ALBIngress.tainted && (ac.finalizerRemove == nil || !*ac.finalizerRemove) {
+			if err := ac.ensureFinalizer(ingResource); err != nil {
+				log.Errorf("Failed to add finalizer to %v: %v", "controller", ALBIngress.id, err)
+			}
+		}
+
 		// Add the new ALBIngress instance to the new ALBIngress list.
 		ALBIngresses = append(ALBIngresses, ALBIngress)
 	}
 
 	// Capture any ingresses missing from the new list that qualify for deletion.
 	deletable := ac.ingressToDelete(ALBIngresses)
-	// If deletable ingresses were found, add them to the list so they'll be deleted when Reconcile()
-	// is called.
+	// If deletable ingresses were found, add them to the list so they remain tracked until GC'd.
 	if len(deletable) > 0 {
 		ALBIngresses = append(ALBIngresses, deletable...)
 	}
 
+	// Index the snapshot by namespace/name and enqueue every key onto the rate-limited work queue,
+	// rather than fanning out a goroutine per ALBIngress here. Ingresses slated for deletion are
+	// enqueued the same way as active ones; they were already stripped of desired state above.
+	ac.ingressIndexMu.Lock()
+	ac.ingressIndex = make(map[string]*ALBIngress, len(ALBIngresses))
+	for _, i := range ALBIngresses {
+		ac.ingressIndex[i.id] = i
+	}
+	ac.ingressIndexMu.Unlock()
+
+	for _, i := range ALBIngresses {
+		ac.workqueue.Add(i.id)
+	}
+	awsutil.QueueDepth.Set(float64(ac.workqueue.Len()))
+
 	awsutil.ManagedIngresses.Set(float64(len(ALBIngresses)))
 	// Update the list of ALBIngresses known to the ALBIngress controller to the newly generated list.
 	ac.ALBIngresses = ALBIngresses
@@ -108,23 +200,11 @@ func (ac ALBController) validIngress(i *extensions.Ingress) bool {
 	return false
 }
 
-// Reload executes the state synchronization for our ingresses
+// Reload is invoked by the generic ingress controller after OnUpdate. The actual state
+// synchronization now happens on the bounded reconcile worker pool draining the rate-limited work
+// queue OnUpdate populates, so there is nothing left to do here.
 func (ac *ALBController) Reload(data []byte) ([]byte, bool, error) {
 	awsutil.ReloadCount.Add(float64(1))
-
-	var wg sync.WaitGroup
-	wg.Add(len(ac.ALBIngresses))
-
-	// Sync the state, resulting in creation, modify, delete, or no action, for every ALBIngress
-	// instance known to the ALBIngress controller.
-	for _, ingress := range ac.ALBIngresses {
-		go func(wg *sync.WaitGroup, ingress *ALBIngress) {
-			defer wg.Done()
-			ingress.Reconcile()
-		}(&wg, ingress)
-	}
-
-	wg.Wait()
 	return []byte(""), true, nil
 }
 
@@ -166,6 +246,10 @@ func (ac *ALBController) DefaultIngressClass() string {
 // ConfigureFlags
 func (ac *ALBController) ConfigureFlags(pf *pflag.FlagSet) {
 	ac.ClusterName = pf.String("cluster-name", "", "The name of the cluster, used for naming AWS resources")
+	ac.finalizerRemove = pf.Bool("finalizer-remove", false, "Force-remove the alb-ingress-controller finalizer from every ingress instead of managing it. Intended for migrating away from the finalizer-based GC model.")
+	ac.namingScheme = pf.String("resource-naming-scheme", namingSchemeV1, "Naming scheme ('v1' or 'v2') used for new AWS resources. v2 derives the unique part of names from a hash of the kube-system namespace UID instead of a truncated ClusterName, removing the 11 character ClusterName limit.")
+	ac.dryRun = pf.Bool("dry-run", false, "Log planned AWS mutations instead of executing them.")
+	ac.reconcileWorkers = pf.Int("reconcile-workers", defaultReconcileWorkers, "Number of worker goroutines draining the per-ingress reconcile queue.")
 }
 
 // Info returns information on the ingress contoller
@@ -202,6 +286,80 @@ func (ac *ALBController) GetServiceNodePort(serviceKey string, backendPort int32
 	return nil, fmt.Errorf("Unable to find a port defined in the %v service", serviceKey)
 }
 
+// GetServiceEndpoints returns the pod IP+containerPort targets currently backing a Kubernetes
+// service, for use when the ip target-type annotation is set and targets are registered with the
+// ALB target group directly instead of through a NodePort.
+func (ac *ALBController) GetServiceEndpoints(serviceKey string, backendPort int32) ([]Target, error) {
+	// EndpointSubset.Ports carries the resolved targetPort, matched to the Service's port by name,
+	// not by the Service's own port number. Look up that name first so differently-numbered
+	// targetPorts aren't skipped and same-numbered-by-coincidence ones aren't wrongly matched.
+	svcItem, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
+	if !exists {
+		return nil, fmt.Errorf("Unable to find the %v service", serviceKey)
+	}
+
+	var portName string
+	for _, p := range svcItem.(*api.Service).Spec.Ports {
+		if p.Port == backendPort {
+			portName = p.Name
+			break
+		}
+	}
+
+	item, exists, _ := ac.storeLister.Endpoint.GetByKey(serviceKey)
+	if !exists {
+		return nil, fmt.Errorf("Unable to find endpoints for the %v service", serviceKey)
+	}
+
+	var targets []Target
+	for _, subset := range item.(*api.Endpoints).Subsets {
+		var port int64
+		for _, p := range subset.Ports {
+			if p.Name == portName {
+				port = int64(p.Port)
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			targets = append(targets, Target{ID: addr.IP, Port: port})
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("Unable to find a ready pod backing the %v service on port %v", serviceKey, backendPort)
+	}
+
+	return targets, nil
+}
+
+// nodeInstanceIDs returns the EC2 instance ID of every node known to the cluster, for use by the
+// "instance" target type where every node, not just one, must be registered with the target group.
+func (ac *ALBController) nodeInstanceIDs() ([]string, error) {
+	var ids []string
+	for _, item := range ac.storeLister.Node.List() {
+		node := item.(*api.Node)
+		id, err := instanceIDFromProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("node %v: %v", node.Name, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// instanceIDFromProviderID extracts the trailing instance ID off a node's spec.providerID, which
+// the AWS cloud provider sets to aws:///<availability-zone>/<instance-id>.
+func instanceIDFromProviderID(providerID string) (string, error) {
+	parts := strings.Split(providerID, "/")
+	id := parts[len(parts)-1]
+	if id == "" {
+		return "", fmt.Errorf("invalid providerID %q", providerID)
+	}
+	return id, nil
+}
+
 // Returns a list of ingress objects that are no longer known to kubernetes and should
 // be deleted.
 func (ac *ALBController) ingressToDelete(newList ALBIngressesT) ALBIngressesT {
@@ -243,25 +401,33 @@ func (ac *ALBController) assembleIngresses() {
 		glog.Fatal(err)
 	}
 
+	// Keep at most reconcileWorkerCount() goroutines running at once instead of starting one per
+	// LoadBalancer all together, and serialize writes to ac.ALBIngresses since it's shared across
+	// those goroutines.
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, ac.reconcileWorkerCount())
 	wg.Add(len(loadBalancers))
 
 	for _, loadBalancer := range loadBalancers {
-		go func(wg *sync.WaitGroup, loadBalancer *elbv2.LoadBalancer) {
-			defer wg.Done()
+		sem <- struct{}{}
+		go func(loadBalancer *elbv2.LoadBalancer) {
+			defer func() { <-sem; wg.Done() }()
 
 			albIngress, ok := NewALBIngressFromLoadBalancer(*ac.ClusterName, loadBalancer)
 			if !ok {
 				return
 			}
 
+			mu.Lock()
+			defer mu.Unlock()
 			if i := ac.ALBIngresses.find(albIngress); i >= 0 {
 				albIngress = ac.ALBIngresses[i]
 				albIngress.LoadBalancers = append(albIngress.LoadBalancers, albIngress.LoadBalancers[0])
 			} else {
 				ac.ALBIngresses = append(ac.ALBIngresses, albIngress)
 			}
-		}(&wg, loadBalancer)
+		}(loadBalancer)
 	}
 	wg.Wait()
 
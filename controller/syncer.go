@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// Syncer decouples the AWS-mutating reconcile logic from ALBController so alternate
+// implementations (dry-run, recording, rate-limited retries) can be swapped in without touching
+// OnUpdate/Reload.
+type Syncer interface {
+	// Sync drives a single ALBIngress towards its desired state.
+	Sync(ingress *ALBIngress) error
+	// GC tears down every ALBIngress in state that is no longer wanted.
+	GC(state ALBIngressesT) error
+}
+
+// awsSyncer is the default Syncer. It performs the AWS-mutating behavior ALBController has always
+// had: Reconcile() each ALBIngress in place.
+type awsSyncer struct{}
+
+func (s *awsSyncer) Sync(ingress *ALBIngress) error {
+	return ingress.Reconcile()
+}
+
+func (s *awsSyncer) GC(state ALBIngressesT) error {
+	for _, ingress := range state {
+		if err := ingress.Reconcile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dryRunSyncer logs the mutations Sync/GC would have performed without touching AWS. Enabled via
+// the --dry-run flag.
+type dryRunSyncer struct{}
+
+func (s *dryRunSyncer) Sync(ingress *ALBIngress) error {
+	log.Infof("dry-run: would sync ingress %v (%d load balancer(s))", "controller", ingress.id, len(ingress.LoadBalancers))
+	return nil
+}
+
+func (s *dryRunSyncer) GC(state ALBIngressesT) error {
+	for _, ingress := range state {
+		log.Infof("dry-run: would delete ingress %v", "controller", ingress.id)
+	}
+	return nil
+}
+
+// recordingSyncer records the ingresses it was asked to sync/GC instead of mutating AWS. Intended
+// for use in tests exercising OnUpdate/Reload without a Syncer that calls out to AWS.
+type recordingSyncer struct {
+	Synced []string
+	GCed   []string
+}
+
+func (s *recordingSyncer) Sync(ingress *ALBIngress) error {
+	s.Synced = append(s.Synced, ingress.id)
+	return nil
+}
+
+func (s *recordingSyncer) GC(state ALBIngressesT) error {
+	for _, ingress := range state {
+		s.GCed = append(s.GCed, ingress.id)
+	}
+	return nil
+}
+
+// activeSyncer returns the dryRunSyncer when --dry-run is set, otherwise the Syncer configured on
+// the controller (the awsSyncer by default).
+func (ac *ALBController) activeSyncer() Syncer {
+	if ac.dryRun != nil && *ac.dryRun {
+		return &dryRunSyncer{}
+	}
+	return ac.Syncer
+}
@@ -0,0 +1,36 @@
+package controller
+
+import "testing"
+
+// TestSyncIngressByKeyRoutesToSyncOrGC exercises the workqueue sync path with a recordingSyncer
+// injected as ac.Syncer, without touching AWS or Kubernetes: active ingresses should go through
+// Sync, ingresses marked deleting should go through GC, and unknown keys should be a no-op.
+func TestSyncIngressByKeyRoutesToSyncOrGC(t *testing.T) {
+	rs := &recordingSyncer{}
+	ac := &ALBController{
+		Syncer:       rs,
+		ingressIndex: map[string]*ALBIngress{},
+	}
+
+	active := &ALBIngress{id: "default/active"}
+	deleting := &ALBIngress{id: "default/deleting", deleting: true}
+	ac.ingressIndex[active.id] = active
+	ac.ingressIndex[deleting.id] = deleting
+
+	if err := ac.syncIngressByKey(active.id); err != nil {
+		t.Fatalf("syncIngressByKey(%v) returned error: %v", active.id, err)
+	}
+	if err := ac.syncIngressByKey(deleting.id); err != nil {
+		t.Fatalf("syncIngressByKey(%v) returned error: %v", deleting.id, err)
+	}
+	if err := ac.syncIngressByKey("default/unknown"); err != nil {
+		t.Fatalf("syncIngressByKey for an unindexed key should be a no-op, got: %v", err)
+	}
+
+	if len(rs.Synced) != 1 || rs.Synced[0] != active.id {
+		t.Errorf("expected Sync to be called once for %v, got %v", active.id, rs.Synced)
+	}
+	if len(rs.GCed) != 1 || rs.GCed[0] != deleting.id {
+		t.Errorf("expected GC to be called once for %v, got %v", deleting.id, rs.GCed)
+	}
+}
@@ -49,8 +49,8 @@ func main() {
 		glog.Exit("A cluster name must be defined")
 	}
 
-	if len(*ac.ClusterName) > 11 {
-		glog.Exit("Cluster name must be 11 characters or less")
+	if !ac.UsesV2Naming() && len(*ac.ClusterName) > 11 {
+		glog.Exit("Cluster name must be 11 characters or less, unless --resource-naming-scheme=v2 is set")
 	}
 
 	defer func() {